@@ -0,0 +1,141 @@
+package repl
+
+import (
+	"flag"
+	"fmt"
+	"io"
+)
+
+// HandlerV2 is a Handler variant for Commands that declare Subcommands,
+// Flags, or Args. It receives the already parsed flags and positional
+// arguments instead of the raw input string.
+type HandlerV2 func(*Context, *ParsedCommand) (string, error)
+
+// FlagsFunc returns a fresh *flag.FlagSet describing the flags a Command
+// accepts. It is called once per invocation so that flag values do not
+// leak between calls.
+type FlagsFunc func() *flag.FlagSet
+
+// ArgSpec describes a single named positional argument accepted by a
+// Command.
+type ArgSpec struct {
+	Name     string
+	Default  string
+	Required bool
+	// Validate, if set, is run against the resolved argument value.
+	// Returning an error aborts dispatch with a non fatal Error.
+	Validate func(string) error
+}
+
+// ParsedCommand exposes the flags and positional arguments parsed for a
+// single invocation of a HandlerV2 command.
+type ParsedCommand struct {
+	Command *Command
+	Flags   *flag.FlagSet
+
+	args  map[string]string
+	extra []string
+}
+
+// Arg returns the resolved value of the named ArgSpec, or "" if no such
+// argument was declared.
+func (p *ParsedCommand) Arg(name string) string {
+	return p.args[name]
+}
+
+// Extra returns any positional arguments left over once every declared
+// ArgSpec has been resolved.
+func (p *ParsedCommand) Extra() []string {
+	return p.extra
+}
+
+// isTree reports whether c should be dispatched through the Subcommand
+// tree rather than its plain Handle.
+func (c *Command) isTree() bool {
+	return c.HandleV2 != nil || len(c.Subcommands) > 0 || c.Flags != nil || len(c.Args) > 0
+}
+
+// dispatchTree tokenizes ctx.Input with shell-style rules, walks root's
+// Subcommands to find the most specific match, and invokes its
+// HandlerV2.
+func (r *Repl) dispatchTree(ctx *Context, root *Command) (string, error) {
+	tokens, err := tokenize(ctx.Input)
+	if err != nil {
+		return "", NewError(err.Error())
+	}
+
+	cmd := root
+	idx := 1
+	for idx < len(tokens) {
+		next := findSubcommand(cmd.Subcommands, tokens[idx])
+		if next == nil {
+			break
+		}
+
+		cmd = next
+		idx++
+	}
+
+	if idx > len(tokens) {
+		idx = len(tokens)
+	}
+
+	return r.invokeV2(ctx, cmd, tokens[idx:])
+}
+
+func findSubcommand(cmds []Command, name string) *Command {
+	for i := range cmds {
+		if cmds[i].Name == name {
+			return &cmds[i]
+		}
+	}
+
+	return nil
+}
+
+func (r *Repl) invokeV2(ctx *Context, cmd *Command, rest []string) (string, error) {
+	var fs *flag.FlagSet
+	if cmd.Flags != nil {
+		fs = cmd.Flags()
+	} else {
+		fs = flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+	}
+	fs.SetOutput(io.Discard)
+
+	if err := fs.Parse(rest); err != nil {
+		return "", NewError(err.Error())
+	}
+
+	positional := fs.Args()
+
+	parsed := &ParsedCommand{Command: cmd, Flags: fs, args: map[string]string{}}
+	for i, spec := range cmd.Args {
+		var v string
+		switch {
+		case i < len(positional):
+			v = positional[i]
+		case spec.Required:
+			return "", NewError(fmt.Sprintf("missing required argument %q", spec.Name))
+		default:
+			v = spec.Default
+		}
+
+		if spec.Validate != nil {
+			if err := spec.Validate(v); err != nil {
+				return "", NewError(err.Error())
+			}
+		}
+
+		parsed.args[spec.Name] = v
+	}
+
+	if len(positional) > len(cmd.Args) {
+		parsed.extra = positional[len(cmd.Args):]
+	}
+
+	if cmd.HandleV2 == nil {
+		return "", NewError(fmt.Sprintf("%q requires a subcommand", cmd.Name))
+	}
+
+	return cmd.HandleV2(ctx, parsed)
+}
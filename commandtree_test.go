@@ -0,0 +1,114 @@
+package repl
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+)
+
+func Test_tokenize(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty", input: "", want: nil},
+		{name: "blank", input: "   ", want: nil},
+		{name: "simple words", input: "foo bar baz", want: []string{"foo", "bar", "baz"}},
+		{name: "single quotes group whitespace", input: "foo 'bar baz'", want: []string{"foo", "bar baz"}},
+		{name: "double quotes honor escapes", input: `foo "bar \"baz\""`, want: []string{"foo", `bar "baz"`}},
+		{name: "bare backslash escapes next rune", input: `foo\ bar`, want: []string{"foo bar"}},
+		{name: "unterminated single quote", input: "foo 'bar", wantErr: true},
+		{name: "unterminated double quote", input: `foo "bar`, wantErr: true},
+		{name: "trailing backslash", input: `foo\`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tokenize(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("tokenize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tokenize() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Repl_dispatchTree_BlankInputDoesNotPanic(t *testing.T) {
+	r := &Repl{ctx: &Context{}}
+
+	cmd := &Command{
+		Name:  "root",
+		Match: AlwaysMatcher(),
+		HandleV2: func(ctx *Context, p *ParsedCommand) (string, error) {
+			return "ok", nil
+		},
+	}
+
+	ctx := &Context{Input: ""}
+	out, err := r.dispatchTree(ctx, cmd)
+	if err != nil {
+		t.Fatalf("dispatchTree() error = %v", err)
+	}
+
+	if out != "ok" {
+		t.Errorf("dispatchTree() = %q, want %q", out, "ok")
+	}
+}
+
+func Test_Repl_invokeV2_ArgResolution(t *testing.T) {
+	r := &Repl{}
+
+	var gotName, gotExtra string
+	cmd := &Command{
+		Name: "greet",
+		Flags: func() *flag.FlagSet {
+			return flag.NewFlagSet("greet", flag.ContinueOnError)
+		},
+		Args: []ArgSpec{{Name: "name", Required: true}},
+		HandleV2: func(ctx *Context, p *ParsedCommand) (string, error) {
+			gotName = p.Arg("name")
+			if len(p.Extra()) > 0 {
+				gotExtra = p.Extra()[0]
+			}
+			return "", nil
+		},
+	}
+
+	if _, err := r.invokeV2(&Context{}, cmd, []string{"alice", "extra"}); err != nil {
+		t.Fatalf("invokeV2() error = %v", err)
+	}
+
+	if gotName != "alice" {
+		t.Errorf("Arg(\"name\") = %q, want %q", gotName, "alice")
+	}
+
+	if gotExtra != "extra" {
+		t.Errorf("Extra()[0] = %q, want %q", gotExtra, "extra")
+	}
+}
+
+func Test_Repl_invokeV2_MissingRequiredArg(t *testing.T) {
+	r := &Repl{}
+
+	cmd := &Command{
+		Name: "greet",
+		Args: []ArgSpec{{Name: "name", Required: true}},
+		HandleV2: func(ctx *Context, p *ParsedCommand) (string, error) {
+			return "", nil
+		},
+	}
+
+	_, err := r.invokeV2(&Context{}, cmd, nil)
+	if err == nil {
+		t.Fatal("invokeV2() error = nil, want missing argument error")
+	}
+}
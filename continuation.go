@@ -0,0 +1,168 @@
+package repl
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// ContinuationFunc reports whether the REPL should keep reading more
+// lines before dispatching the accumulated buffer. An error aborts the
+// REPL the same as any other fatal error.
+type ContinuationFunc func(buffered string) (needMore bool, err error)
+
+// WithContinuation sets the ContinuationFunc applied to every line read
+// by the REPL before it is dispatched to Match/Handle, unless the matched
+// Command sets its own Continuation, which is used instead. While the
+// function reports needMore, the prompt switches to SecondaryPrompt and
+// further lines are appended, separated by newlines, to the buffer.
+func (r *Repl) WithContinuation(fn ContinuationFunc) *Repl {
+	r.Continuation = fn
+	return r
+}
+
+// WithSecondaryPrompt sets the Prompter used while a Continuation is
+// requesting more input. When unset, "> " is used.
+func (r *Repl) WithSecondaryPrompt(p Prompter) *Repl {
+	r.SecondaryPrompt = p
+	return r
+}
+
+func (r *Repl) secondaryPrompt() (string, error) {
+	if r.SecondaryPrompt == nil {
+		return "> ", nil
+	}
+
+	return r.SecondaryPrompt(r.ctx)
+}
+
+// accumulate repeatedly reads lines and appends them to buf, using the
+// secondary prompt, for as long as cont reports that more input is
+// needed.
+func (r *Repl) accumulate(buf string, cont ContinuationFunc) (string, error) {
+	for {
+		needMore, err := cont(buf)
+		if err != nil {
+			return "", err
+		} else if !needMore {
+			return buf, nil
+		}
+
+		prompt, err := r.secondaryPrompt()
+		if err != nil {
+			return "", err
+		}
+
+		line, err := r.readInput(prompt)
+		if err != nil {
+			return "", err
+		}
+
+		buf += "\n" + line
+	}
+}
+
+// matchedContinuation resolves the ContinuationFunc that should accumulate
+// further lines for input: the Continuation of the first Command whose
+// Match does not return ErrNoMatch, if it set one, or r.Continuation
+// otherwise. This is what lets a Command's Continuation apply in place
+// of the Repl's, as documented on Command.Continuation, rather than
+// stacking both.
+func (r *Repl) matchedContinuation(input string) ContinuationFunc {
+	for i := range r.Commands {
+		if errors.Is(r.Commands[i].Match(input), ErrNoMatch) {
+			continue
+		}
+
+		if r.Commands[i].Continuation != nil {
+			return r.Commands[i].Continuation
+		}
+
+		break
+	}
+
+	return r.Continuation
+}
+
+var heredocStart = regexp.MustCompile(`<<(\w+)`)
+
+// DefaultContinuation returns a ContinuationFunc that requests more input
+// while the buffered text contains unbalanced (), [], or {}, a string
+// literal opened with ' or " that hasn't been closed (respecting \
+// escapes), or an open `<<TAG` heredoc that hasn't seen a line containing
+// only TAG.
+func DefaultContinuation() ContinuationFunc {
+	return func(buffered string) (bool, error) {
+		return needsMoreInput(buffered), nil
+	}
+}
+
+func needsMoreInput(s string) bool {
+	var stack []byte
+	var quote byte
+	escaped := false
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		if quote != 0 {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == quote:
+				quote = 0
+			}
+
+			continue
+		}
+
+		switch c {
+		case '\'', '"':
+			quote = c
+		case '(', '[', '{':
+			stack = append(stack, c)
+		case ')', ']', '}':
+			if len(stack) > 0 && bracketsMatch(stack[len(stack)-1], c) {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+
+	if quote != 0 || len(stack) > 0 {
+		return true
+	}
+
+	return heredocOpen(s)
+}
+
+func bracketsMatch(open, close byte) bool {
+	switch open {
+	case '(':
+		return close == ')'
+	case '[':
+		return close == ']'
+	case '{':
+		return close == '}'
+	default:
+		return false
+	}
+}
+
+func heredocOpen(s string) bool {
+	m := heredocStart.FindStringSubmatchIndex(s)
+	if m == nil {
+		return false
+	}
+
+	tag := s[m[2]:m[3]]
+
+	for _, line := range strings.Split(s[m[1]:], "\n") {
+		if line == tag {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,135 @@
+package repl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_needsMoreInput(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want bool
+	}{
+		{name: "balanced", in: "foo(bar)", want: false},
+		{name: "unbalanced paren", in: "foo(bar", want: true},
+		{name: "nested brackets balanced", in: "foo([{bar}])", want: false},
+		{name: "nested brackets unbalanced", in: "foo([{bar])", want: true},
+		{name: "open single quote", in: "foo 'bar", want: true},
+		{name: "closed single quote", in: "foo 'bar'", want: false},
+		{name: "escaped quote inside string", in: `foo 'bar\'baz'`, want: false},
+		{name: "open heredoc", in: "cat <<EOF\nhello", want: true},
+		{name: "closed heredoc", in: "cat <<EOF\nhello\nEOF", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := needsMoreInput(tt.in); got != tt.want {
+				t.Errorf("needsMoreInput(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_DefaultContinuation(t *testing.T) {
+	cont := DefaultContinuation()
+
+	needMore, err := cont("foo(bar")
+	if err != nil {
+		t.Fatalf("DefaultContinuation() error = %v", err)
+	}
+	if !needMore {
+		t.Error("DefaultContinuation() needMore = false, want true for unbalanced input")
+	}
+
+	needMore, err = cont("foo(bar)")
+	if err != nil {
+		t.Fatalf("DefaultContinuation() error = %v", err)
+	}
+	if needMore {
+		t.Error("DefaultContinuation() needMore = true, want false for balanced input")
+	}
+}
+
+func Test_Repl_accumulate(t *testing.T) {
+	lines := []string{"bar)"}
+	r := &Repl{
+		ctx: &Context{},
+		Editor: &queueEditor{lines: lines},
+	}
+
+	got, err := r.accumulate("foo(", DefaultContinuation())
+	if err != nil {
+		t.Fatalf("accumulate() error = %v", err)
+	}
+
+	want := "foo(\nbar)"
+	if got != want {
+		t.Errorf("accumulate() = %q, want %q", got, want)
+	}
+}
+
+// queueEditor returns successive lines from a fixed queue, for driving
+// accumulate in tests without a real terminal.
+type queueEditor struct {
+	lines []string
+	i     int
+}
+
+func (e *queueEditor) ReadLine(prompt string) (string, error) {
+	line := e.lines[e.i]
+	e.i++
+	return line, nil
+}
+
+func Test_Repl_matchedContinuation(t *testing.T) {
+	global := DefaultContinuation()
+	perCommand := func(string) (bool, error) { return false, nil }
+
+	t.Run("falls back to the Repl's Continuation when no command overrides it", func(t *testing.T) {
+		r := &Repl{
+			Continuation: global,
+			Commands:     []Command{{Match: StringMatcher("quit")}},
+		}
+
+		got := r.matchedContinuation("anything")
+		if funcAddr(got) != funcAddr(ContinuationFunc(global)) {
+			t.Error("matchedContinuation() did not return the Repl's Continuation")
+		}
+	})
+
+	t.Run("uses the matched command's Continuation instead of the Repl's", func(t *testing.T) {
+		r := &Repl{
+			Continuation: global,
+			Commands: []Command{
+				{Match: StringMatcher("sql"), Continuation: perCommand},
+			},
+		}
+
+		got := r.matchedContinuation("sql")
+		if funcAddr(got) != funcAddr(ContinuationFunc(perCommand)) {
+			t.Error("matchedContinuation() did not return the matched command's Continuation")
+		}
+	})
+
+	t.Run("falls back to the Repl's Continuation when the matched command has none", func(t *testing.T) {
+		r := &Repl{
+			Continuation: global,
+			Commands: []Command{
+				{Match: StringMatcher("quit")},
+			},
+		}
+
+		got := r.matchedContinuation("quit")
+		if funcAddr(got) != funcAddr(ContinuationFunc(global)) {
+			t.Error("matchedContinuation() did not fall back to the Repl's Continuation")
+		}
+	})
+}
+
+// funcAddr returns an opaque identity for a func value so tests can
+// assert two ContinuationFuncs are the same underlying function without
+// relying on reflect.DeepEqual, which funcs don't support.
+func funcAddr(f ContinuationFunc) uintptr {
+	return reflect.ValueOf(f).Pointer()
+}
@@ -0,0 +1,103 @@
+package repl
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// registerHelp appends a help [cmd...] Command that walks the Subcommand
+// tree rooted at r.Commands, unless the caller has already registered
+// their own "help" command.
+func (r *Repl) registerHelp() {
+	for _, c := range r.Commands {
+		if c.Name == "help" {
+			return
+		}
+	}
+
+	r.Commands = append(r.Commands, Command{
+		Name:   "help",
+		Usage:  "Print usage information for a command",
+		Match:  helpMatcher,
+		Handle: r.handleHelp,
+	})
+}
+
+func helpMatcher(input string) error {
+	if input == "help" || strings.HasPrefix(input, "help ") {
+		return nil
+	}
+
+	return ErrNoMatch
+}
+
+func (r *Repl) handleHelp(ctx *Context) (string, error) {
+	tokens, err := tokenize(ctx.Input)
+	if err != nil {
+		return "", NewError(err.Error())
+	}
+
+	path := tokens[1:]
+	if len(path) == 0 {
+		return r.usageAll(), nil
+	}
+
+	cmds := r.Commands
+	var cmd *Command
+	for _, name := range path {
+		next := findSubcommand(cmds, name)
+		if next == nil {
+			return "", NewError(fmt.Sprintf("unknown command %q", name))
+		}
+
+		cmd = next
+		cmds = next.Subcommands
+	}
+
+	return usageFor(cmd), nil
+}
+
+func (r *Repl) usageAll() string {
+	var b strings.Builder
+
+	b.WriteString("Available commands:\n")
+	for _, c := range r.Commands {
+		if c.Name == "" {
+			continue
+		}
+
+		fmt.Fprintf(&b, "  %-12s %s\n", c.Name, c.Usage)
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func usageFor(cmd *Command) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s - %s\n", cmd.Name, cmd.Usage)
+
+	if cmd.Flags != nil {
+		fs := cmd.Flags()
+
+		var wroteHeader bool
+		fs.VisitAll(func(f *flag.Flag) {
+			if !wroteHeader {
+				b.WriteString("\nFlags:\n")
+				wroteHeader = true
+			}
+
+			fmt.Fprintf(&b, "  -%-10s default %q: %s\n", f.Name, f.DefValue, f.Usage)
+		})
+	}
+
+	if len(cmd.Subcommands) > 0 {
+		b.WriteString("\nSubcommands:\n")
+		for _, sc := range cmd.Subcommands {
+			fmt.Fprintf(&b, "  %-12s %s\n", sc.Name, sc.Usage)
+		}
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
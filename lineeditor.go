@@ -0,0 +1,537 @@
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// CompleterFunc returns the completion candidates for the input line at
+// the given cursor position. prefixLen reports how many runes immediately
+// before pos should be replaced by whichever candidate is chosen.
+type CompleterFunc func(ctx *Context, line string, pos int) (candidates []string, prefixLen int)
+
+// LineEditor reads a single line of input from the user. Implementations
+// may support cursor movement, history recall, and completion beyond what
+// a plain bufio.Reader offers.
+type LineEditor interface {
+	// ReadLine prints prompt and reads a single line of input. ErrExit is
+	// returned when the user signals end of input with Ctrl-D on an empty
+	// line.
+	ReadLine(prompt string) (string, error)
+}
+
+// WithLineEditor overrides the LineEditor used to read input. Unless
+// overridden, Run installs a TermLineEditor wrapping os.Stdin.
+func (r *Repl) WithLineEditor(e LineEditor) *Repl {
+	r.Editor = e
+	return r
+}
+
+// WithCompleter registers the function used to drive Tab completion. A
+// single Tab cycles through the returned candidates on repeated presses;
+// the first press against an ambiguous match set lists the candidates
+// below the current line. If no completer is registered, completion is
+// driven by the names of the Repl's registered Commands.
+func (r *Repl) WithCompleter(fn CompleterFunc) *Repl {
+	r.completer = fn
+	return r
+}
+
+// WithHistoryFile configures History to be loaded from, and appended to,
+// the file at path. size bounds the in-memory History ring buffer.
+// Consecutive duplicate entries are not persisted.
+func (r *Repl) WithHistoryFile(path string, size int) *Repl {
+	r.historyFile = path
+	r.historyFileSize = size
+	return r
+}
+
+// defaultCompleter builds completion candidates from the names of the
+// Repl's registered top level Commands.
+func (r *Repl) defaultCompleter(ctx *Context, line string, pos int) ([]string, int) {
+	prefix := string([]rune(line)[:pos])
+	start := strings.LastIndexByte(prefix, ' ') + 1
+	word := prefix[start:]
+
+	var candidates []string
+	for _, c := range r.Commands {
+		if c.Name != "" && strings.HasPrefix(c.Name, word) {
+			candidates = append(candidates, c.Name)
+		}
+	}
+
+	sort.Strings(candidates)
+
+	return candidates, utf8.RuneCountInString(word)
+}
+
+// ensureHistory makes sure r.History is populated, loading persisted
+// entries from r.historyFile when one has been configured via
+// WithHistoryFile.
+func (r *Repl) ensureHistory() error {
+	if r.History == nil {
+		size := r.historyFileSize
+		if size <= 0 {
+			size = 100
+		}
+
+		r.History = NewHistory(size)
+	}
+
+	if r.historyFile == "" {
+		return nil
+	}
+
+	f, err := os.Open(r.historyFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		r.History.Append(scanner.Text())
+	}
+
+	return scanner.Err()
+}
+
+func (r *Repl) appendHistoryFile(line string) error {
+	if r.historyFile == "" {
+		return nil
+	}
+
+	f, err := os.OpenFile(r.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintln(f, line)
+	return err
+}
+
+// TermLineEditor is the default LineEditor. The first ReadLine or
+// WatchCancel call puts the terminal backed by in into raw mode and
+// starts a single background reader that both later calls share, so at
+// most one goroutine is ever reading from in. ReadLine supports the
+// usual readline-style bindings: the left/right arrows and Ctrl-A/E move
+// the cursor, the up/down arrows recall History, Ctrl-K/U/W kill to the
+// end of the line, the start of the line, and the previous word, Ctrl-C
+// cancels the line being edited, Ctrl-D on an empty line exits, Ctrl-R
+// starts a reverse incremental History search, and Tab triggers the
+// owning Repl's Completer. When in is not a terminal, ReadLine falls
+// back to a plain line read. Call Close to restore the terminal once the
+// editor is no longer needed.
+type TermLineEditor struct {
+	in   *os.File
+	out  io.Writer
+	repl *Repl
+	fd   int
+
+	rawOnce sync.Once
+	state   *term.State
+	bytesCh chan byte
+	errCh   chan error
+}
+
+// NewTermLineEditor creates a TermLineEditor that reads from in and
+// writes editing feedback to out. r is used to resolve History and the
+// Completer.
+func NewTermLineEditor(in *os.File, out io.Writer, r *Repl) *TermLineEditor {
+	return &TermLineEditor{in: in, out: out, repl: r, fd: int(in.Fd())}
+}
+
+// ensureRaw puts the terminal into raw mode and starts the shared byte
+// reader the first time it is called. It reports whether raw-mode input
+// is available; when it is not (in isn't a terminal, or raw mode could
+// not be entered), callers should fall back to a plain read.
+func (e *TermLineEditor) ensureRaw() (bytesCh <-chan byte, errCh <-chan error, ok bool) {
+	e.rawOnce.Do(func() {
+		if !term.IsTerminal(e.fd) {
+			return
+		}
+
+		state, err := term.MakeRaw(e.fd)
+		if err != nil {
+			return
+		}
+
+		e.state = state
+		e.bytesCh = make(chan byte)
+		e.errCh = make(chan error, 1)
+
+		go func() {
+			buf := make([]byte, 1)
+			for {
+				n, err := e.in.Read(buf)
+				if err != nil {
+					e.errCh <- err
+					return
+				}
+
+				if n > 0 {
+					e.bytesCh <- buf[0]
+				}
+			}
+		}()
+	})
+
+	return e.bytesCh, e.errCh, e.bytesCh != nil
+}
+
+// Close restores the terminal to the mode it was in before the first
+// ReadLine or WatchCancel call. It is a no-op if raw mode was never
+// entered.
+func (e *TermLineEditor) Close() error {
+	if e.state == nil {
+		return nil
+	}
+
+	return term.Restore(e.fd, e.state)
+}
+
+// ReadLine implements LineEditor.
+func (e *TermLineEditor) ReadLine(prompt string) (string, error) {
+	bytesCh, errCh, ok := e.ensureRaw()
+	if !ok {
+		return readLinePlain(e.in, prompt, e.out)
+	}
+
+	fmt.Fprint(e.out, prompt)
+
+	ln := &lineState{editor: e, prompt: prompt, bytesCh: bytesCh, errCh: errCh}
+	return ln.run()
+}
+
+func readLinePlain(in *os.File, prompt string, out io.Writer) (string, error) {
+	fmt.Fprint(out, prompt)
+
+	reader := bufio.NewReader(in)
+	s, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(s, "\r\n"), nil
+}
+
+// readRune assembles one UTF-8 rune from the shared byte reader.
+func readRune(bytesCh <-chan byte, errCh <-chan error) (rune, error) {
+	var buf []byte
+
+	for {
+		if len(buf) > 0 && (utf8.FullRune(buf) || len(buf) >= utf8.UTFMax) {
+			r, _ := utf8.DecodeRune(buf)
+			return r, nil
+		}
+
+		select {
+		case b := <-bytesCh:
+			buf = append(buf, b)
+		case err := <-errCh:
+			return 0, err
+		}
+	}
+}
+
+// lineState tracks the in-progress edit of a single line under raw mode.
+type lineState struct {
+	editor  *TermLineEditor
+	prompt  string
+	bytesCh <-chan byte
+	errCh   <-chan error
+	buf     []rune
+	pos     int
+
+	historyIdx int
+	pending    string
+
+	tabCandidates []string
+	tabBase       string
+	tabIndex      int
+}
+
+func (l *lineState) readRune() (rune, error) {
+	return readRune(l.bytesCh, l.errCh)
+}
+
+func (l *lineState) run() (string, error) {
+	for {
+		r, err := l.readRune()
+		if err != nil {
+			return "", err
+		}
+
+		if r != 9 {
+			l.tabCandidates = nil
+		}
+
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(l.editor.out, "\r\n")
+			line := string(l.buf)
+			l.commitHistory(line)
+			return line, nil
+		case 3: // Ctrl-C
+			fmt.Fprint(l.editor.out, "^C\r\n")
+			return "", nil
+		case 4: // Ctrl-D
+			if len(l.buf) == 0 {
+				fmt.Fprint(l.editor.out, "\r\n")
+				return "", ErrExit
+			}
+		case 1: // Ctrl-A
+			l.pos = 0
+		case 5: // Ctrl-E
+			l.pos = len(l.buf)
+		case 11: // Ctrl-K
+			l.buf = l.buf[:l.pos]
+		case 21: // Ctrl-U
+			l.buf = l.buf[l.pos:]
+			l.pos = 0
+		case 23: // Ctrl-W
+			l.deleteWordBack()
+		case 18: // Ctrl-R
+			line, submitted, err := l.reverseSearch()
+			if err != nil {
+				return "", err
+			} else if submitted {
+				l.commitHistory(line)
+				return line, nil
+			}
+			l.redraw()
+			continue
+		case 9: // Tab
+			l.complete()
+		case 127, 8: // Backspace
+			if l.pos > 0 {
+				l.buf = append(l.buf[:l.pos-1], l.buf[l.pos:]...)
+				l.pos--
+			}
+		case 27: // Escape sequence
+			if err := l.handleEscape(); err != nil {
+				return "", err
+			}
+		default:
+			if r >= 0x20 {
+				l.buf = append(l.buf[:l.pos:l.pos], append([]rune{r}, l.buf[l.pos:]...)...)
+				l.pos++
+			}
+		}
+
+		l.redraw()
+	}
+}
+
+func (l *lineState) handleEscape() error {
+	b1, err := l.readRune()
+	if err != nil {
+		return err
+	} else if b1 != '[' {
+		return nil
+	}
+
+	b2, err := l.readRune()
+	if err != nil {
+		return err
+	}
+
+	switch b2 {
+	case 'A': // Up
+		l.historyPrev()
+	case 'B': // Down
+		l.historyNext()
+	case 'C': // Right
+		if l.pos < len(l.buf) {
+			l.pos++
+		}
+	case 'D': // Left
+		if l.pos > 0 {
+			l.pos--
+		}
+	}
+
+	return nil
+}
+
+func (l *lineState) setBuf(s string) {
+	l.buf = []rune(s)
+	l.pos = len(l.buf)
+}
+
+func (l *lineState) historyPrev() {
+	h := l.editor.repl.History
+	if h == nil || h.Cap <= 0 {
+		return
+	}
+
+	if l.historyIdx == 0 {
+		l.pending = string(l.buf)
+	}
+
+	if l.historyIdx >= h.Cap {
+		return
+	}
+
+	l.historyIdx++
+	l.setBuf(h.Get(uint(l.historyIdx - 1)))
+}
+
+func (l *lineState) historyNext() {
+	h := l.editor.repl.History
+	if h == nil || l.historyIdx == 0 {
+		return
+	}
+
+	l.historyIdx--
+	if l.historyIdx == 0 {
+		l.setBuf(l.pending)
+		return
+	}
+
+	l.setBuf(h.Get(uint(l.historyIdx - 1)))
+}
+
+func (l *lineState) deleteWordBack() {
+	end := l.pos
+	start := end
+	for start > 0 && l.buf[start-1] == ' ' {
+		start--
+	}
+	for start > 0 && l.buf[start-1] != ' ' {
+		start--
+	}
+
+	l.buf = append(l.buf[:start], l.buf[end:]...)
+	l.pos = start
+}
+
+func (l *lineState) complete() {
+	if l.tabCandidates != nil {
+		l.tabIndex = (l.tabIndex + 1) % len(l.tabCandidates)
+		l.setBuf(l.tabBase + l.tabCandidates[l.tabIndex])
+		return
+	}
+
+	completer := l.editor.repl.completer
+	if completer == nil {
+		completer = l.editor.repl.defaultCompleter
+	}
+
+	candidates, prefixLen := completer(l.editor.repl.ctx, string(l.buf), l.pos)
+	if len(candidates) == 0 {
+		return
+	}
+
+	base := string(l.buf[:l.pos-prefixLen])
+	if len(candidates) > 1 {
+		fmt.Fprintf(l.editor.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+		l.tabCandidates = candidates
+		l.tabBase = base
+		l.tabIndex = 0
+	}
+
+	l.setBuf(base + candidates[0] + string(l.buf[l.pos:]))
+}
+
+func (l *lineState) commitHistory(line string) {
+	if line == "" {
+		return
+	}
+
+	h := l.editor.repl.History
+	if h == nil || h.Cap <= 0 {
+		return
+	}
+
+	if h.Get(0) == line {
+		return
+	}
+
+	h.Append(line)
+	l.editor.repl.appendHistoryFile(line)
+}
+
+func (l *lineState) redraw() {
+	out := l.editor.out
+	fmt.Fprintf(out, "\r\x1b[K%s%s", l.prompt, string(l.buf))
+	if back := len(l.buf) - l.pos; back > 0 {
+		fmt.Fprintf(out, "\x1b[%dD", back)
+	}
+}
+
+// reverseSearch runs an incremental, Ctrl-R-triggered search backwards
+// through History. It returns the accepted line and submitted=true when
+// Enter is pressed during the search, or submitted=false with the line
+// state left positioned on the current match when the search is aborted
+// with Escape.
+func (l *lineState) reverseSearch() (string, bool, error) {
+	h := l.editor.repl.History
+
+	var query []rune
+	match := ""
+	offset := 0
+
+	search := func() {
+		if h == nil || h.Cap <= 0 {
+			return
+		}
+
+		for i := offset; i < h.Cap; i++ {
+			if c := h.Get(uint(i)); strings.Contains(c, string(query)) {
+				match = c
+				offset = i
+				return
+			}
+		}
+	}
+
+	render := func() {
+		fmt.Fprintf(l.editor.out, "\r\x1b[K(reverse-i-search)`%s': %s", string(query), match)
+	}
+	render()
+
+	for {
+		r, err := l.readRune()
+		if err != nil {
+			return "", false, err
+		}
+
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(l.editor.out, "\r\n")
+			return match, true, nil
+		case 27:
+			l.setBuf(match)
+			return "", false, nil
+		case 127, 8:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				offset = 0
+				search()
+			}
+		case 18:
+			offset++
+			search()
+		default:
+			if r >= 0x20 {
+				query = append(query, r)
+				offset = 0
+				search()
+			}
+		}
+
+		render()
+	}
+}
@@ -0,0 +1,145 @@
+package repl
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_lineState_deleteWordBack(t *testing.T) {
+	tests := []struct {
+		name    string
+		buf     string
+		pos     int
+		wantBuf string
+		wantPos int
+	}{
+		{name: "deletes the word before the cursor", buf: "foo bar", pos: 7, wantBuf: "foo ", wantPos: 4},
+		{name: "skips trailing whitespace first", buf: "foo bar  ", pos: 9, wantBuf: "foo ", wantPos: 4},
+		{name: "stops at the start of the buffer", buf: "foo", pos: 3, wantBuf: "", wantPos: 0},
+		{name: "leaves text after the cursor untouched", buf: "foo bar", pos: 4, wantBuf: "bar", wantPos: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &lineState{buf: []rune(tt.buf), pos: tt.pos}
+			l.deleteWordBack()
+
+			if string(l.buf) != tt.wantBuf {
+				t.Errorf("buf = %q, want %q", string(l.buf), tt.wantBuf)
+			}
+			if l.pos != tt.wantPos {
+				t.Errorf("pos = %d, want %d", l.pos, tt.wantPos)
+			}
+		})
+	}
+}
+
+func Test_lineState_historyPrevNext(t *testing.T) {
+	h := NewHistory(4)
+	h.Append("first")
+	h.Append("second")
+	h.Append("third")
+
+	editor := &TermLineEditor{repl: &Repl{History: h}}
+	l := &lineState{editor: editor}
+	l.setBuf("unsent")
+
+	l.historyPrev()
+	if got := string(l.buf); got != "third" {
+		t.Fatalf("after one historyPrev, buf = %q, want %q", got, "third")
+	}
+
+	l.historyPrev()
+	if got := string(l.buf); got != "second" {
+		t.Fatalf("after two historyPrev, buf = %q, want %q", got, "second")
+	}
+
+	l.historyNext()
+	if got := string(l.buf); got != "third" {
+		t.Fatalf("after historyNext back, buf = %q, want %q", got, "third")
+	}
+
+	l.historyNext()
+	if got := string(l.buf); got != "unsent" {
+		t.Fatalf("after returning past the most recent entry, buf = %q, want the pending line %q", got, "unsent")
+	}
+}
+
+func Test_lineState_complete(t *testing.T) {
+	t.Run("single candidate completes in place", func(t *testing.T) {
+		r := &Repl{Commands: []Command{{Name: "quit"}, {Name: "query"}, {Name: "help"}}}
+		editor := &TermLineEditor{repl: r, out: &bytes.Buffer{}}
+		l := &lineState{editor: editor}
+		l.setBuf("he")
+
+		l.complete()
+
+		if got := string(l.buf); got != "help" {
+			t.Errorf("buf = %q, want %q", got, "help")
+		}
+	})
+
+	t.Run("repeated tab cycles ambiguous candidates", func(t *testing.T) {
+		r := &Repl{Commands: []Command{{Name: "quit"}, {Name: "query"}}}
+		editor := &TermLineEditor{repl: r, out: &bytes.Buffer{}}
+		l := &lineState{editor: editor}
+		l.setBuf("qu")
+
+		l.complete()
+		first := string(l.buf)
+
+		l.complete()
+		second := string(l.buf)
+
+		if first == second {
+			t.Fatalf("second Tab press did not cycle to the other candidate, got %q both times", first)
+		}
+
+		for _, got := range []string{first, second} {
+			if got != "quit" && got != "query" {
+				t.Errorf("buf = %q, want one of %q", got, []string{"quit", "query"})
+			}
+		}
+	})
+}
+
+func Test_readRune(t *testing.T) {
+	t.Run("assembles a multi-byte UTF-8 rune", func(t *testing.T) {
+		bytesCh := make(chan byte, 4)
+		errCh := make(chan error, 1)
+
+		// 'é' (U+00E9) encodes to the two bytes 0xC3 0xA9.
+		want := 'é'
+		buf := []byte(string(want))
+		for _, b := range buf {
+			bytesCh <- b
+		}
+
+		got, err := readRune(bytesCh, errCh)
+		if err != nil {
+			t.Fatalf("readRune() error = %v", err)
+		}
+		if got != want {
+			t.Errorf("readRune() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("propagates a reader error", func(t *testing.T) {
+		bytesCh := make(chan byte)
+		errCh := make(chan error, 1)
+		wantErr := errReadTest
+
+		errCh <- wantErr
+
+		_, err := readRune(bytesCh, errCh)
+		if err != wantErr {
+			t.Errorf("readRune() error = %v, want %v", err, wantErr)
+		}
+	})
+}
+
+var errReadTest = &testReadError{}
+
+type testReadError struct{}
+
+func (e *testReadError) Error() string { return "test read error" }
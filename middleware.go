@@ -0,0 +1,151 @@
+package repl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Middleware wraps a Handler to add cross-cutting behavior around every
+// command invocation without editing each Handler. Because Hook shares
+// Handler's signature, the same Middleware chain wraps both.
+type Middleware func(Handler) Handler
+
+// Use appends middleware to the chain wrapped around every Match-selected
+// Handler, HandleV2, and Stream invocation, as well as every Hook.
+// Middleware registered first runs outermost, so it sees the raw call
+// before later middleware and the final error after.
+func (r *Repl) Use(mw ...Middleware) *Repl {
+	r.middleware = append(r.middleware, mw...)
+	return r
+}
+
+func (r *Repl) wrapHandler(h Handler) Handler {
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		h = r.middleware[i](h)
+	}
+
+	return h
+}
+
+func (r *Repl) nextRequestID() string {
+	if r.requestSeq == nil {
+		r.requestSeq = new(uint64)
+	}
+
+	return fmt.Sprintf("req-%d", atomic.AddUint64(r.requestSeq, 1))
+}
+
+// errorClass classifies a Handler/Hook error the way runLoop treats a
+// Handler's error (ErrExit exits, a non fatal Error is printed and the
+// loop continues, any other error is fatal), with ErrNoMatch given a
+// class of its own, for use in middleware that reports on dispatch
+// outcomes. A Hook's error is classified the same way, although runLoop
+// treats every Hook error as fatal.
+type errorClass string
+
+const (
+	classOK         errorClass = "ok"
+	classNoMatch    errorClass = "NoMatch"
+	classError      errorClass = "Error"
+	classFatalError errorClass = "FatalError"
+	classExit       errorClass = "Exit"
+)
+
+func classifyError(err error) errorClass {
+	var replErr Error
+
+	switch {
+	case err == nil:
+		return classOK
+	case errors.Is(err, ErrNoMatch):
+		return classNoMatch
+	case errors.Is(err, ErrExit):
+		return classExit
+	case errors.As(err, &replErr) && !replErr.Fatal:
+		return classError
+	default:
+		return classFatalError
+	}
+}
+
+// LoggingMiddleware returns a Middleware that writes one structured log
+// line per invocation to w, recording the request id, the matched
+// command name, the raw input, how long the call took, and its
+// errorClass.
+func LoggingMiddleware(w io.Writer) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx *Context) (string, error) {
+			start := time.Now()
+			out, err := next(ctx)
+
+			var name string
+			if ctx.Command != nil {
+				name = ctx.Command.Name
+			}
+
+			fmt.Fprintf(w, "request_id=%s command=%q input=%q duration=%s status=%s\n",
+				ctx.RequestID, name, ctx.Input, time.Since(start), classifyError(err))
+
+			return out, err
+		}
+	}
+}
+
+// MetricsMiddleware returns a Middleware that registers and updates a
+// repl_commands_total{name,status} counter and a
+// repl_command_duration_seconds{name} histogram on reg for every
+// invocation.
+func MetricsMiddleware(reg prometheus.Registerer) Middleware {
+	commandsTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "repl_commands_total",
+		Help: "Total number of REPL commands dispatched, by command name and status.",
+	}, []string{"name", "status"})
+
+	commandDuration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "repl_command_duration_seconds",
+		Help: "REPL command handler duration in seconds, by command name.",
+	}, []string{"name"})
+
+	reg.MustRegister(commandsTotal, commandDuration)
+
+	return func(next Handler) Handler {
+		return func(ctx *Context) (string, error) {
+			start := time.Now()
+			out, err := next(ctx)
+
+			var name string
+			if ctx.Command != nil {
+				name = ctx.Command.Name
+			}
+
+			commandsTotal.WithLabelValues(name, string(classifyError(err))).Inc()
+			commandDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+
+			return out, err
+		}
+	}
+}
+
+// RecoveryMiddleware returns a Middleware that recovers a panicking
+// Handler/Hook, attaching its stack trace to ctx.Stack and converting
+// the panic into a non fatal Error so the REPL keeps running.
+func RecoveryMiddleware() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx *Context) (out string, err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					ctx.Stack = string(debug.Stack())
+					err = NewError(fmt.Sprintf("panic: %v", p))
+				}
+			}()
+
+			return next(ctx)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package repl
+
+import "testing"
+
+func Test_classifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want errorClass
+	}{
+		{name: "nil", err: nil, want: classOK},
+		{name: "no match", err: ErrNoMatch, want: classNoMatch},
+		{name: "exit", err: ErrExit, want: classExit},
+		{name: "fatal error", err: NewFatalError("boom"), want: classFatalError},
+		{name: "non fatal error", err: NewError("boom"), want: classError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyError(tt.err); got != tt.want {
+				t.Errorf("classifyError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Repl_wrapHandler_RunsMiddlewareInOrder(t *testing.T) {
+	var order []string
+
+	mw := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx *Context) (string, error) {
+				order = append(order, name+":before")
+				s, err := next(ctx)
+				order = append(order, name+":after")
+				return s, err
+			}
+		}
+	}
+
+	r := &Repl{}
+	r.Use(mw("outer"), mw("inner"))
+
+	_, err := r.wrapHandler(func(ctx *Context) (string, error) {
+		order = append(order, "handler")
+		return "", nil
+	})(&Context{})
+	if err != nil {
+		t.Fatalf("wrapHandler() error = %v", err)
+	}
+
+	want := []string{"outer:before", "inner:before", "handler", "inner:after", "outer:after"}
+	if len(order) != len(want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+
+	for i := range want {
+		if order[i] != want[i] {
+			t.Errorf("order[%d] = %q, want %q", i, order[i], want[i])
+		}
+	}
+}
+
+func Test_RecoveryMiddleware_RecoversPanic(t *testing.T) {
+	handler := RecoveryMiddleware()(func(ctx *Context) (string, error) {
+		panic("boom")
+	})
+
+	ctx := &Context{}
+	_, err := handler(ctx)
+	if err == nil {
+		t.Fatal("handler() error = nil, want non-nil after panic")
+	}
+
+	if ctx.Stack == "" {
+		t.Error("ctx.Stack was not populated by RecoveryMiddleware")
+	}
+}
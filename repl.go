@@ -26,6 +26,31 @@ type Repl struct {
 	PostEval Hook
 	PostRun  Hook
 
+	// Editor reads each line of input. When nil, Run installs a
+	// TermLineEditor wrapping os.Stdin.
+	Editor LineEditor
+	// History holds the previously entered lines for the Editor's
+	// history navigation. When nil, Run installs a default sized History.
+	History *History
+
+	// Continuation, when set, is applied to every line read before it is
+	// dispatched to Match/Handle, unless the matched Command sets its own
+	// Continuation. See WithContinuation.
+	Continuation ContinuationFunc
+	// SecondaryPrompt is shown in place of Prompt while a Continuation is
+	// requesting more input.
+	SecondaryPrompt Prompter
+
+	completer       CompleterFunc
+	historyFile     string
+	historyFileSize int
+	pagerCmd        string
+	middleware      []Middleware
+	// requestSeq is a pointer so that Serve's per-session clones (each a
+	// shallow copy of this Repl) share one counter instead of each
+	// starting back at req-1.
+	requestSeq *uint64
+
 	ctx *Context
 }
 
@@ -86,6 +111,23 @@ type Context struct {
 	ctx context.Context
 
 	Input string
+
+	// RemoteAddr is the client's network address when the Repl is
+	// served over a Transport other than stdio.
+	RemoteAddr string
+	// User is the identity the client authenticated as, e.g. the SSH
+	// username. Empty outside of SSHTransport.
+	User string
+
+	// Command is the Command currently being matched or dispatched, so
+	// middleware and handlers can introspect what ran.
+	Command *Command
+	// RequestID identifies the current read-match-dispatch cycle. It is
+	// regenerated on every loop iteration.
+	RequestID string
+	// Stack holds the stack trace of the most recently recovered panic.
+	// Populated by RecoveryMiddleware.
+	Stack string
 }
 
 // Context returns the context.Context held within the Repl's Context.
@@ -100,6 +142,25 @@ type Command struct {
 	Usage  string
 	Match  Matcher
 	Handle Handler
+
+	// Continuation opts this Command into multi-line input. Once Match
+	// succeeds, it is applied in place of the Repl's Continuation to
+	// accumulate further lines before Handle is called.
+	Continuation ContinuationFunc
+
+	// Subcommands, Flags, and Args opt this Command into tree dispatch:
+	// once Match succeeds, ctx.Input is tokenized with shell-style
+	// rules, the most specific matching Subcommand is resolved, its
+	// Flags parsed and Args validated, and HandleV2 is called with the
+	// result instead of Handle.
+	Subcommands []Command
+	Flags       FlagsFunc
+	Args        []ArgSpec
+	HandleV2    HandlerV2
+
+	// Stream, when set, is used instead of Handle/HandleV2 so the
+	// command can write its output as it is produced. See StreamHandler.
+	Stream StreamHandler
 }
 
 // Matcher is a function that takes the user's input and determines if the command's
@@ -240,6 +301,20 @@ func (r *Repl) Run() error {
 
 	r.ctx = &Context{ctx: context.Background()}
 
+	if r.Editor == nil {
+		r.Editor = NewTermLineEditor(os.Stdin, r.Output, r)
+	}
+
+	if closer, ok := r.Editor.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	if err := r.ensureHistory(); err != nil {
+		return err
+	}
+
+	r.registerHelp()
+
 	err = r.runHook(r.PreRun)
 	if err != nil {
 		return err
@@ -263,7 +338,9 @@ func (r *Repl) runHook(hook Hook) error {
 		return nil
 	}
 
-	s, err := hook(r.ctx)
+	wrapped := r.wrapHandler(Handler(hook))
+
+	s, err := wrapped(r.ctx)
 	if err != nil {
 		return err
 	} else if s != "" {
@@ -280,16 +357,30 @@ func (r *Repl) runLoop() error {
 			return err
 		}
 
-		err = r.printPrompt()
+		prompt, err := r.Prompt(r.ctx)
 		if err != nil {
 			return err
 		}
 
-		r.ctx.Input, err = r.readInput()
+		r.ctx.Input, err = r.readInput(prompt)
+		if errors.Is(err, ErrExit) {
+			return nil
+		}
 		if err != nil {
 			return err
 		}
 
+		if cont := r.matchedContinuation(r.ctx.Input); cont != nil {
+			r.ctx.Input, err = r.accumulate(r.ctx.Input, cont)
+			if errors.Is(err, ErrExit) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		}
+
+		r.ctx.RequestID = r.nextRequestID()
+
 		for _, command := range r.Commands {
 			var replErr Error
 			err := command.Match(r.ctx.Input)
@@ -305,7 +396,20 @@ func (r *Repl) runLoop() error {
 				return err
 			}
 
-			output, err := command.Handle(r.ctx)
+			r.ctx.Command = &command
+
+			var handler Handler
+			switch {
+			case command.Stream != nil:
+				handler = func(ctx *Context) (string, error) { return r.dispatchStream(&command) }
+			case command.isTree():
+				handler = func(ctx *Context) (string, error) { return r.dispatchTree(ctx, &command) }
+			default:
+				handler = command.Handle
+			}
+
+			output, err := r.wrapHandler(handler)(r.ctx)
+
 			if errors.Is(err, ErrExit) {
 				return nil
 			} else if errors.As(err, &replErr) {
@@ -330,19 +434,17 @@ func (r *Repl) runLoop() error {
 	}
 }
 
-func (r *Repl) printPrompt() error {
-	p, err := r.Prompt(r.ctx)
-	if err != nil {
-		return err
-	}
-
-	fmt.Fprint(r.Output, p)
+func (r *Repl) readInput(prompt string) (string, error) {
+	var input string
+	var err error
 
-	return nil
-}
+	if r.Editor != nil {
+		input, err = r.Editor.ReadLine(prompt)
+	} else {
+		fmt.Fprint(r.Output, prompt)
+		input, err = r.Input.ReadString('\n')
+	}
 
-func (r *Repl) readInput() (string, error) {
-	input, err := r.Input.ReadString('\n')
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,87 @@
+package repl
+
+import "testing"
+
+// stubEditor returns a fixed line from ReadLine, unmodified, so tests can
+// assert on what readInput does with it.
+type stubEditor struct {
+	line string
+}
+
+func (e *stubEditor) ReadLine(prompt string) (string, error) {
+	return e.line, nil
+}
+
+// countingEditor returns successive lines from a fixed queue, like
+// queueEditor, but also records how many times ReadLine was called so
+// tests can assert on how many extra lines a Continuation consumed.
+type countingEditor struct {
+	lines []string
+	calls int
+}
+
+func (e *countingEditor) ReadLine(prompt string) (string, error) {
+	line := e.lines[e.calls]
+	e.calls++
+	return line, nil
+}
+
+// Test_Repl_runLoop_CommandContinuationOverridesGlobal guards against a
+// Command's Continuation stacking with the Repl's rather than replacing
+// it, as documented on Command.Continuation: the Repl's Continuation
+// here would always request another line if it were consulted, so a
+// second ReadLine call means the override didn't take effect.
+func Test_Repl_runLoop_CommandContinuationOverridesGlobal(t *testing.T) {
+	editor := &countingEditor{lines: []string{"cmd"}}
+
+	r := &Repl{
+		ctx:    &Context{},
+		Editor: editor,
+		Prompt: func(ctx *Context) (string, error) { return "", nil },
+		Continuation: func(buffered string) (bool, error) {
+			return true, nil
+		},
+		Commands: []Command{
+			{
+				Match:        StringMatcher("cmd"),
+				Continuation: func(buffered string) (bool, error) { return false, nil },
+				Handle:       func(ctx *Context) (string, error) { return "", ErrExit },
+			},
+		},
+	}
+
+	if err := r.runLoop(); err != nil {
+		t.Fatalf("runLoop() error = %v", err)
+	}
+
+	if editor.calls != 1 {
+		t.Errorf("ReadLine called %d times, want 1", editor.calls)
+	}
+}
+
+func Test_Repl_readInput_TrimsEditorLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{name: "padded both sides", line: "  quit  ", want: "quit"},
+		{name: "no padding", line: "quit", want: "quit"},
+		{name: "all whitespace", line: "   ", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Repl{Editor: &stubEditor{line: tt.line}}
+
+			got, err := r.readInput("> ")
+			if err != nil {
+				t.Fatalf("readInput() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("readInput() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// sshHandshakeTimeout bounds how long a client has to complete the SSH
+// handshake and open its session channel. Without it, a client that
+// opens the TCP connection and then stalls would block forever, since
+// the handshake runs concurrently with other clients rather than inline
+// in Accept.
+const sshHandshakeTimeout = 10 * time.Second
+
+// SSHTransport accepts SSH connections on addr, authorizes clients
+// against authorizedKeys, and hands each authorized client a Session
+// backed by its shell channel. The authenticated username is recorded
+// as Context.User.
+type SSHTransport struct {
+	addr           string
+	authorizedKeys []ssh.PublicKey
+	config         *ssh.ServerConfig
+	listener       net.Listener
+
+	sessions chan *Session
+	done     <-chan struct{}
+}
+
+// NewSSHTransport creates an SSHTransport listening on addr once Accept
+// is first called. hostKey identifies the server to connecting clients;
+// authorizedKeys is the set of client public keys permitted to connect.
+func NewSSHTransport(addr string, hostKey ssh.Signer, authorizedKeys []ssh.PublicKey) *SSHTransport {
+	t := &SSHTransport{addr: addr, authorizedKeys: authorizedKeys}
+
+	config := &ssh.ServerConfig{PublicKeyCallback: t.authorize}
+	config.AddHostKey(hostKey)
+	t.config = config
+
+	return t
+}
+
+func (t *SSHTransport) authorize(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	for _, k := range t.authorizedKeys {
+		if bytes.Equal(k.Marshal(), key.Marshal()) {
+			return &ssh.Permissions{}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("ssh: unauthorized key for user %q", conn.User())
+}
+
+// Accept implements Transport. The TCP accept loop and each connection's
+// SSH handshake run in their own goroutines, so a client that stalls
+// during the handshake cannot block other clients from connecting.
+func (t *SSHTransport) Accept(ctx context.Context) (*Session, error) {
+	if t.listener == nil {
+		l, err := net.Listen("tcp", t.addr)
+		if err != nil {
+			return nil, err
+		}
+
+		t.listener = l
+		t.sessions = make(chan *Session)
+		t.done = ctx.Done()
+
+		go func() {
+			<-ctx.Done()
+			t.listener.Close()
+		}()
+
+		go t.acceptLoop()
+	}
+
+	select {
+	case sess := <-t.sessions:
+		return sess, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// acceptLoop accepts raw TCP connections and hands each one to its own
+// handshake goroutine, until the listener is closed.
+func (t *SSHTransport) acceptLoop() {
+	for {
+		conn, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go t.handshake(conn)
+	}
+}
+
+// handshake runs the SSH handshake and channel negotiation for conn,
+// bounded by sshHandshakeTimeout, and delivers the resulting Session to
+// Accept. If ctx is cancelled before the Session can be delivered, it is
+// closed instead of leaking.
+func (t *SSHTransport) handshake(conn net.Conn) {
+	conn.SetDeadline(time.Now().Add(sshHandshakeTimeout))
+
+	sess, err := t.acceptShell(conn)
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	select {
+	case t.sessions <- sess:
+	case <-t.done:
+		sess.closer.Close()
+	}
+}
+
+func (t *SSHTransport) acceptShell(conn net.Conn) (*Session, error) {
+	sshConn, chans, reqs, err := ssh.NewServerConn(conn, t.config)
+	if err != nil {
+		return nil, err
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChan := range chans {
+		if newChan.ChannelType() != "session" {
+			newChan.Reject(ssh.UnknownChannelType, "only session channels are supported")
+			continue
+		}
+
+		channel, requests, err := newChan.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		go acceptShellRequests(requests)
+
+		return &Session{
+			Input:  bufio.NewReader(channel),
+			Output: channel,
+			Editor: newPtyLineEditor(channel, channel),
+			ctx: &Context{
+				RemoteAddr: sshConn.RemoteAddr().String(),
+				User:       sshConn.User(),
+			},
+			closer: channel,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("ssh: client disconnected before opening a session channel")
+}
+
+// acceptShellRequests acknowledges the pty-req/shell requests a typical
+// SSH client sends when opening an interactive session, and rejects
+// anything else (e.g. exec, subsystem).
+func acceptShellRequests(requests <-chan *ssh.Request) {
+	for req := range requests {
+		switch req.Type {
+		case "shell", "pty-req":
+			req.Reply(true, nil)
+		default:
+			req.Reply(false, nil)
+		}
+	}
+}
+
+// Close implements Transport.
+func (t *SSHTransport) Close() error {
+	if t.listener == nil {
+		return nil
+	}
+
+	return t.listener.Close()
+}
@@ -0,0 +1,56 @@
+package repl
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func newTestPublicKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey() error = %v", err)
+	}
+
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("ssh.NewPublicKey() error = %v", err)
+	}
+
+	return sshPub
+}
+
+type fakeConnMetadata struct {
+	ssh.ConnMetadata
+	user string
+}
+
+func (m fakeConnMetadata) User() string { return m.user }
+
+func Test_SSHTransport_authorize(t *testing.T) {
+	authorized := newTestPublicKey(t)
+	unauthorized := newTestPublicKey(t)
+
+	transport := &SSHTransport{authorizedKeys: []ssh.PublicKey{authorized}}
+
+	t.Run("accepts an authorized key", func(t *testing.T) {
+		perms, err := transport.authorize(fakeConnMetadata{user: "alice"}, authorized)
+		if err != nil {
+			t.Fatalf("authorize() error = %v, want nil", err)
+		}
+		if perms == nil {
+			t.Error("authorize() perms = nil, want non-nil on success")
+		}
+	})
+
+	t.Run("rejects a key not on the allow list", func(t *testing.T) {
+		_, err := transport.authorize(fakeConnMetadata{user: "mallory"}, unauthorized)
+		if err == nil {
+			t.Fatal("authorize() error = nil, want an error for an unauthorized key")
+		}
+	})
+}
@@ -0,0 +1,241 @@
+package repl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// StreamHandler is a Handler variant for long-running commands that
+// should write their output as it is produced rather than buffering it
+// into a single string. The context returned by Context.Context is
+// cancelled if the user presses Ctrl-C while the handler is running.
+type StreamHandler func(*Context, io.Writer) error
+
+// WithPager sets the external command streamed output is piped through
+// once registered via Command.Stream, e.g. "less -R". When unset, each
+// streamed command uses "less -R" if Output is a terminal, or a
+// dependency-free internal pager otherwise.
+func (r *Repl) WithPager(cmd string) *Repl {
+	r.pagerCmd = cmd
+	return r
+}
+
+// CancelWatcher is implemented by LineEditors that can watch for a
+// cancellation keystroke (Ctrl-C) without blocking on a full line read,
+// so a StreamHandler in progress can be interrupted.
+type CancelWatcher interface {
+	// WatchCancel returns a channel that is closed when Ctrl-C is read,
+	// and a done channel that is closed once the watcher has fully
+	// stopped, whether because Ctrl-C was read, stop was closed, or the
+	// underlying reader failed. Callers must wait on done before starting
+	// another read against the same input, e.g. the next ReadLine call.
+	WatchCancel(stop <-chan struct{}) (cancel <-chan struct{}, done <-chan struct{})
+}
+
+// WatchCancel implements CancelWatcher. It consumes from the same shared
+// byte reader ReadLine uses, rather than starting a second reader of in,
+// so no two goroutines ever race to read the next keystroke.
+func (e *TermLineEditor) WatchCancel(stop <-chan struct{}) (<-chan struct{}, <-chan struct{}) {
+	cancelCh := make(chan struct{})
+	doneCh := make(chan struct{})
+
+	bytesCh, errCh, ok := e.ensureRaw()
+	if !ok {
+		close(doneCh)
+		return cancelCh, doneCh
+	}
+
+	go func() {
+		defer close(doneCh)
+
+		for {
+			select {
+			case <-stop:
+				return
+			case b := <-bytesCh:
+				if b == 3 { // Ctrl-C
+					close(cancelCh)
+					return
+				}
+			case <-errCh:
+				return
+			}
+		}
+	}()
+
+	return cancelCh, doneCh
+}
+
+// dispatchStream runs command.Stream, piping its output through a pager
+// and cancelling the Context it receives if the user presses Ctrl-C.
+func (r *Repl) dispatchStream(command *Command) (string, error) {
+	ctx, cancel := context.WithCancel(r.ctx.Context())
+	defer cancel()
+
+	streamCtx := &Context{
+		ctx:        ctx,
+		Input:      r.ctx.Input,
+		RemoteAddr: r.ctx.RemoteAddr,
+		User:       r.ctx.User,
+		Command:    r.ctx.Command,
+		RequestID:  r.ctx.RequestID,
+		Stack:      r.ctx.Stack,
+	}
+
+	stop := make(chan struct{})
+
+	var done <-chan struct{}
+	if watcher, ok := r.Editor.(CancelWatcher); ok {
+		var watchCancel <-chan struct{}
+		watchCancel, done = watcher.WatchCancel(stop)
+
+		go func() {
+			select {
+			case <-watchCancel:
+				cancel()
+			case <-stop:
+			}
+		}()
+	}
+
+	out, closeOut := r.pagedOutput()
+	defer closeOut()
+
+	err := command.Stream(streamCtx, out)
+
+	close(stop)
+	if done != nil {
+		<-done
+	}
+
+	return "", err
+}
+
+func (r *Repl) pagedOutput() (io.Writer, func()) {
+	cmd := r.pagerCmd
+	if cmd == "" && r.isOutputTTY() {
+		cmd = "less -R"
+	}
+
+	if cmd != "" {
+		if p, err := newExternalPager(cmd, r.Output); err == nil {
+			return p, func() { p.Close() }
+		}
+	}
+
+	width, height := r.terminalSize()
+	p := &internalPager{out: r.Output, width: width, height: height}
+
+	return p, func() {}
+}
+
+func (r *Repl) isOutputTTY() bool {
+	f, ok := r.Output.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
+
+func (r *Repl) terminalSize() (width, height int) {
+	if f, ok := r.Output.(*os.File); ok {
+		if w, h, err := term.GetSize(int(f.Fd())); err == nil {
+			return w, h
+		}
+	}
+
+	return 80, 24
+}
+
+// externalPager pipes written bytes to an external pager process such
+// as "less", which owns presenting the output a screen at a time.
+type externalPager struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+}
+
+func newExternalPager(cmdline string, out io.Writer) (*externalPager, error) {
+	parts := strings.Fields(cmdline)
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("repl: empty pager command")
+	}
+
+	cmd := exec.Command(parts[0], parts[1:]...)
+	cmd.Stdout = out
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	return &externalPager{cmd: cmd, stdin: stdin}, nil
+}
+
+func (p *externalPager) Write(b []byte) (int, error) { return p.stdin.Write(b) }
+
+func (p *externalPager) Close() error {
+	p.stdin.Close()
+	return p.cmd.Wait()
+}
+
+// ansiPattern matches ANSI CSI escape sequences so internalPager can
+// measure a line's visible width without being thrown off by color
+// codes.
+var ansiPattern = regexp.MustCompile("\x1b\\[[0-9;]*[a-zA-Z]")
+
+func visibleWidth(s string) int {
+	return len([]rune(ansiPattern.ReplaceAllString(s, "")))
+}
+
+// internalPager is a dependency-free pager used when no external pager
+// command is appropriate, e.g. output destined for a non-tty Transport
+// session. It passes output through as it arrives, printing a
+// "-- More --" marker every height's worth of visible terminal rows.
+type internalPager struct {
+	out    io.Writer
+	width  int
+	height int
+	rows   int
+}
+
+func (p *internalPager) Write(b []byte) (int, error) {
+	for _, line := range strings.SplitAfter(string(b), "\n") {
+		if line == "" {
+			continue
+		}
+
+		if _, err := io.WriteString(p.out, line); err != nil {
+			return 0, err
+		}
+
+		if !strings.HasSuffix(line, "\n") {
+			continue
+		}
+
+		rows := 1
+		if p.width > 0 {
+			if w := visibleWidth(line); w > p.width {
+				rows = (w + p.width - 1) / p.width
+			}
+		}
+
+		p.rows += rows
+		if p.rows >= p.height-1 {
+			fmt.Fprint(p.out, "-- More --\r\n")
+			p.rows = 0
+		}
+	}
+
+	return len(b), nil
+}
+
+func (p *internalPager) Close() error { return nil }
@@ -0,0 +1,130 @@
+package repl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func Test_visibleWidth(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want int
+	}{
+		{name: "plain text", in: "hello", want: 5},
+		{name: "strips color escape codes", in: "\x1b[31mhello\x1b[0m", want: 5},
+		{name: "empty", in: "", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := visibleWidth(tt.in); got != tt.want {
+				t.Errorf("visibleWidth(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_internalPager_Write(t *testing.T) {
+	t.Run("passes lines through untouched", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		p := &internalPager{out: out, width: 80, height: 24}
+
+		if _, err := p.Write([]byte("hello\nworld\n")); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+
+		if got := out.String(); got != "hello\nworld\n" {
+			t.Errorf("out = %q, want %q", got, "hello\nworld\n")
+		}
+	})
+
+	t.Run("prints a More marker once the page fills up", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		p := &internalPager{out: out, width: 80, height: 3}
+
+		for i := 0; i < 3; i++ {
+			if _, err := p.Write([]byte("line\n")); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+		}
+
+		if got := out.String(); !bytes.Contains([]byte(got), []byte("-- More --")) {
+			t.Errorf("out = %q, want it to contain a -- More -- marker", got)
+		}
+	})
+}
+
+// fakeCancelWatcherEditor is a LineEditor + CancelWatcher stub that lets
+// tests control when Ctrl-C is "read" and when the watcher reports done,
+// without a real terminal.
+type fakeCancelWatcherEditor struct {
+	cancelCh chan struct{}
+	doneWait func(stop <-chan struct{}) <-chan struct{}
+}
+
+func (e *fakeCancelWatcherEditor) ReadLine(prompt string) (string, error) { return "", nil }
+
+func (e *fakeCancelWatcherEditor) WatchCancel(stop <-chan struct{}) (<-chan struct{}, <-chan struct{}) {
+	return e.cancelCh, e.doneWait(stop)
+}
+
+func Test_Repl_dispatchStream_CancelsContextOnWatcherCancel(t *testing.T) {
+	cancelCh := make(chan struct{})
+	editor := &fakeCancelWatcherEditor{
+		cancelCh: cancelCh,
+		doneWait: func(stop <-chan struct{}) <-chan struct{} {
+			done := make(chan struct{})
+			go func() { <-stop; close(done) }()
+			return done
+		},
+	}
+
+	r := &Repl{ctx: &Context{ctx: context.Background()}, Editor: editor}
+
+	cmd := &Command{Stream: func(ctx *Context, w io.Writer) error {
+		close(cancelCh) // simulate the watcher reading Ctrl-C mid-stream
+		<-ctx.Context().Done()
+		return ctx.Context().Err()
+	}}
+
+	_, err := r.dispatchStream(cmd)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("dispatchStream() error = %v, want context.Canceled", err)
+	}
+}
+
+func Test_Repl_dispatchStream_WaitsForWatcherDone(t *testing.T) {
+	const doneDelay = 50 * time.Millisecond
+
+	editor := &fakeCancelWatcherEditor{
+		cancelCh: make(chan struct{}),
+		doneWait: func(stop <-chan struct{}) <-chan struct{} {
+			done := make(chan struct{})
+			go func() {
+				<-stop
+				time.Sleep(doneDelay)
+				close(done)
+			}()
+			return done
+		},
+	}
+
+	r := &Repl{ctx: &Context{ctx: context.Background()}, Editor: editor}
+
+	cmd := &Command{Stream: func(ctx *Context, w io.Writer) error { return nil }}
+
+	start := time.Now()
+	if _, err := r.dispatchStream(cmd); err != nil {
+		t.Fatalf("dispatchStream() error = %v", err)
+	}
+	elapsed := time.Since(start)
+
+	if elapsed < doneDelay {
+		t.Errorf("dispatchStream() returned after %s, want it to wait at least %s for the watcher to stop", elapsed, doneDelay)
+	}
+}
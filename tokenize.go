@@ -0,0 +1,84 @@
+package repl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tokenize splits s the way a POSIX shell would split a command line:
+// whitespace separates tokens, single and double quotes group whitespace
+// into a single token (double quotes still honor \ escapes), and a bare
+// \ escapes the following rune. An unterminated quote or a trailing \ is
+// reported as an error.
+func tokenize(s string) ([]string, error) {
+	var tokens []string
+	var cur strings.Builder
+	inToken := false
+
+	runes := []rune(s)
+	i := 0
+
+	for i < len(runes) {
+		c := runes[i]
+
+		switch {
+		case c == ' ' || c == '\t':
+			if inToken {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+				inToken = false
+			}
+			i++
+		case c == '\'':
+			inToken = true
+			i++
+			for {
+				if i >= len(runes) {
+					return nil, fmt.Errorf("unterminated ' quote")
+				} else if runes[i] == '\'' {
+					i++
+					break
+				}
+
+				cur.WriteRune(runes[i])
+				i++
+			}
+		case c == '"':
+			inToken = true
+			i++
+			for {
+				if i >= len(runes) {
+					return nil, fmt.Errorf(`unterminated " quote`)
+				} else if runes[i] == '"' {
+					i++
+					break
+				}
+
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+
+				cur.WriteRune(runes[i])
+				i++
+			}
+		case c == '\\':
+			if i+1 >= len(runes) {
+				return nil, fmt.Errorf("trailing \\")
+			}
+
+			inToken = true
+			cur.WriteRune(runes[i+1])
+			i += 2
+		default:
+			inToken = true
+			cur.WriteRune(c)
+			i++
+		}
+	}
+
+	if inToken {
+		tokens = append(tokens, cur.String())
+	}
+
+	return tokens, nil
+}
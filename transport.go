@@ -0,0 +1,285 @@
+package repl
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Session bundles the per-connection state a Transport hands to Serve:
+// its input/output streams, an optional LineEditor and History, and a
+// Context carrying the client's identity. A nil Editor, History, or
+// Context is given a default by Serve; Input and Output are used as
+// given.
+type Session struct {
+	Input   *bufio.Reader
+	Output  io.Writer
+	Editor  LineEditor
+	History *History
+
+	ctx    *Context
+	closer io.Closer
+}
+
+// Transport accepts client connections and hands each one a fresh
+// Session to run a Repl loop against.
+type Transport interface {
+	// Accept blocks until a new Session is available or ctx is
+	// cancelled, in which case it must return ctx.Err().
+	Accept(ctx context.Context) (*Session, error)
+	// Close stops accepting new Sessions. An Accept call already in
+	// progress may keep blocking until its ctx is cancelled, as it does
+	// for StdioTransport and SSHTransport.
+	Close() error
+}
+
+// sessionBinder lets a LineEditor wire itself to the Repl it is running
+// under once that Repl is known, e.g. for History and Completer lookups.
+type sessionBinder interface {
+	bindRepl(r *Repl)
+}
+
+func (e *TermLineEditor) bindRepl(r *Repl) { e.repl = r }
+
+// plainLineEditor is a LineEditor with no editing support beyond a plain
+// line read. It is the default for Sessions not backed by a local
+// terminal, e.g. ones produced by TCPTransport.
+type plainLineEditor struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func (e *plainLineEditor) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(e.out, prompt)
+
+	s, err := e.in.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(s, "\r\n"), nil
+}
+
+// ptyLineEditor is a LineEditor for Sessions backed by a remote pty, e.g.
+// an interactive SSH client that requested one via pty-req. Once a pty
+// has been requested, the client's local terminal is in raw mode and
+// defers editing to the server: Enter arrives as a bare \r (never \n, so
+// plainLineEditor's ReadString('\n') would block forever), input arrives
+// one keystroke at a time instead of a buffered line, and nothing is
+// echoed back to the client unless the server does so itself.
+type ptyLineEditor struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func newPtyLineEditor(in io.Reader, out io.Writer) *ptyLineEditor {
+	return &ptyLineEditor{in: bufio.NewReader(in), out: out}
+}
+
+func (e *ptyLineEditor) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(e.out, prompt)
+
+	var buf []rune
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case '\r':
+			if next, err := e.in.Peek(1); err == nil && next[0] == '\n' {
+				e.in.Discard(1)
+			}
+			fmt.Fprint(e.out, "\r\n")
+			return string(buf), nil
+		case '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(buf), nil
+		case 4: // Ctrl-D
+			if len(buf) == 0 {
+				return "", ErrExit
+			}
+		case 127, 8: // Backspace
+			if len(buf) > 0 {
+				buf = buf[:len(buf)-1]
+				fmt.Fprint(e.out, "\b \b")
+			}
+		default:
+			if r >= 0x20 {
+				buf = append(buf, r)
+				fmt.Fprint(e.out, string(r))
+			}
+		}
+	}
+}
+
+// Serve runs the Repl concurrently over every Session t.Accept produces,
+// until ctx is cancelled or Accept returns a non context error. Each
+// Session gets its own History, LineEditor, and Context derived from
+// this Repl's configuration (Commands, Prompt, hooks, Continuation, and
+// so on), so per-session state never leaks between clients. Serve
+// returns once every in-flight session has finished.
+func (r *Repl) Serve(ctx context.Context, t Transport) error {
+	defer t.Close()
+
+	r.registerHelp()
+
+	// Allocate the request counter once, up front, so every session
+	// clone below shares it instead of each starting back at req-1.
+	if r.requestSeq == nil {
+		r.requestSeq = new(uint64)
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		sess, err := t.Accept(ctx)
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := r.runSession(ctx, sess); err != nil && !errors.Is(err, io.EOF) {
+				fmt.Fprintf(sess.Output, "%v\n", err)
+			}
+		}()
+	}
+}
+
+func (r *Repl) runSession(ctx context.Context, sess *Session) error {
+	if sess.closer != nil {
+		defer sess.closer.Close()
+	}
+
+	clone := *r
+	clone.Input = sess.Input
+	clone.Output = sess.Output
+	clone.Editor = sess.Editor
+	clone.History = sess.History
+	clone.ctx = sess.ctx
+	if clone.ctx == nil {
+		clone.ctx = &Context{}
+	}
+	clone.ctx.ctx = ctx
+
+	if clone.Editor == nil {
+		clone.Editor = &plainLineEditor{in: clone.Input, out: clone.Output}
+	} else if binder, ok := clone.Editor.(sessionBinder); ok {
+		binder.bindRepl(&clone)
+	}
+
+	if closer, ok := clone.Editor.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	if err := clone.ensureHistory(); err != nil {
+		return err
+	}
+
+	if err := clone.runHook(clone.PreRun); err != nil {
+		return err
+	}
+
+	if err := clone.runLoop(); err != nil {
+		return err
+	}
+
+	return clone.runHook(clone.PostRun)
+}
+
+// StdioTransport serves a single Session backed by the process's own
+// stdin/stdout. A second Accept call blocks until ctx is cancelled.
+type StdioTransport struct {
+	served bool
+}
+
+// NewStdioTransport creates a StdioTransport.
+func NewStdioTransport() *StdioTransport {
+	return &StdioTransport{}
+}
+
+// Accept implements Transport.
+func (t *StdioTransport) Accept(ctx context.Context) (*Session, error) {
+	if t.served {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	t.served = true
+
+	return &Session{
+		Input:  bufio.NewReader(os.Stdin),
+		Output: os.Stdout,
+		Editor: NewTermLineEditor(os.Stdin, os.Stdout, nil),
+		ctx:    &Context{},
+	}, nil
+}
+
+// Close implements Transport.
+func (t *StdioTransport) Close() error { return nil }
+
+// TCPTransport accepts plain TCP connections on addr and hands each one
+// a Session whose Input/Output wrap the connection.
+type TCPTransport struct {
+	addr     string
+	listener net.Listener
+}
+
+// NewTCPTransport creates a TCPTransport listening on addr once Accept
+// is first called.
+func NewTCPTransport(addr string) *TCPTransport {
+	return &TCPTransport{addr: addr}
+}
+
+// Accept implements Transport.
+func (t *TCPTransport) Accept(ctx context.Context) (*Session, error) {
+	if t.listener == nil {
+		l, err := net.Listen("tcp", t.addr)
+		if err != nil {
+			return nil, err
+		}
+
+		t.listener = l
+		go func() {
+			<-ctx.Done()
+			t.listener.Close()
+		}()
+	}
+
+	conn, err := t.listener.Accept()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		return nil, err
+	}
+
+	return &Session{
+		Input:  bufio.NewReader(conn),
+		Output: conn,
+		ctx:    &Context{RemoteAddr: conn.RemoteAddr().String()},
+		closer: conn,
+	}, nil
+}
+
+// Close implements Transport.
+func (t *TCPTransport) Close() error {
+	if t.listener == nil {
+		return nil
+	}
+
+	return t.listener.Close()
+}
@@ -0,0 +1,92 @@
+package repl
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Repl_Serve_TCPTransport_GracefulShutdown(t *testing.T) {
+	transport := NewTCPTransport("127.0.0.1:0")
+	r := &Repl{ctx: &Context{}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- r.Serve(ctx, transport)
+	}()
+
+	// Give Accept a moment to start listening before tearing it down.
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Serve() error = %v, want nil", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Serve() did not return after context cancellation")
+	}
+}
+
+// Test_Repl_requestSeq_SharedAcrossSessionClones guards against
+// runSession's `clone := *r` giving each session its own independent
+// request counter: two clones of a Repl whose requestSeq has already
+// been allocated (as Serve does before fanning sessions out) must still
+// hand out distinct request ids.
+func Test_Repl_requestSeq_SharedAcrossSessionClones(t *testing.T) {
+	r := &Repl{}
+	r.requestSeq = new(uint64)
+
+	cloneA := *r
+	cloneB := *r
+
+	idA := cloneA.nextRequestID()
+	idB := cloneB.nextRequestID()
+
+	if idA == idB {
+		t.Errorf("cloneA and cloneB both produced request id %q, want distinct ids", idA)
+	}
+}
+
+func Test_ptyLineEditor_ReadLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "bare CR terminates the line", input: "quit\r", want: "quit"},
+		{name: "CRLF terminates the line without a trailing blank read", input: "quit\r\nnext", want: "quit"},
+		{name: "bare LF also terminates the line", input: "quit\n", want: "quit"},
+		{name: "backspace removes the previous rune", input: "quix\x7ft\r", want: "quit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			e := newPtyLineEditor(strings.NewReader(tt.input), out)
+
+			got, err := e.ReadLine("> ")
+			if err != nil {
+				t.Fatalf("ReadLine() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ReadLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("Ctrl-D on an empty line exits", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		e := newPtyLineEditor(strings.NewReader("\x04"), out)
+
+		_, err := e.ReadLine("> ")
+		if err != ErrExit {
+			t.Errorf("ReadLine() error = %v, want ErrExit", err)
+		}
+	})
+}